@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// subcommand describes one of state's subcommands: its own flag set and
+// a build function that turns the parsed flags and remaining positional
+// arguments into a Salt module name and its arguments.
+type subcommand struct {
+	name  string
+	usage string
+	flags *pflag.FlagSet
+	build func(rest []string) (module string, args []string, err error)
+}
+
+// commands is the registry of all known subcommands, keyed by name (and
+// any aliases).
+var commands = map[string]*subcommand{}
+
+func register(cmd *subcommand, aliases ...string) {
+	commands[cmd.name] = cmd
+	for _, alias := range aliases {
+		commands[alias] = cmd
+	}
+}
+
+func init() {
+	register(slsCommand())
+	register(highstateCommand(), "up")
+	register(clearCommand())
+	register(testCommand())
+	register(pillarCommand())
+	register(grainsCommand())
+	register(cmdCommand())
+}
+
+func slsCommand() *subcommand {
+	fs := pflag.NewFlagSet("sls", pflag.ExitOnError)
+	test := fs.Bool("test", false, "Run in test mode; don't apply any changes.")
+	pillar := fs.String("pillar", "", "JSON pillar data to override for this run.")
+
+	return &subcommand{
+		name:  "sls",
+		usage: "sls [--test] [--pillar JSON] <state> [states...]",
+		flags: fs,
+		build: func(rest []string) (string, []string, error) {
+			if len(rest) == 0 {
+				return "", nil, fmt.Errorf("sls requires at least one state name")
+			}
+			args := append([]string{}, rest...)
+			if *test {
+				args = append(args, "test=True")
+			}
+			if *pillar != "" {
+				args = append(args, "pillar="+*pillar)
+			}
+			return "state.sls", args, nil
+		},
+	}
+}
+
+func highstateCommand() *subcommand {
+	fs := pflag.NewFlagSet("highstate", pflag.ExitOnError)
+	test := fs.Bool("test", false, "Run in test mode; don't apply any changes.")
+	pillar := fs.String("pillar", "", "JSON pillar data to override for this run.")
+
+	return &subcommand{
+		name:  "highstate",
+		usage: "highstate [--test] [--pillar JSON]",
+		flags: fs,
+		build: func(rest []string) (string, []string, error) {
+			args := append([]string{}, rest...)
+			if *test {
+				args = append(args, "test=True")
+			}
+			if *pillar != "" {
+				args = append(args, "pillar="+*pillar)
+			}
+			return "state.highstate", args, nil
+		},
+	}
+}
+
+func clearCommand() *subcommand {
+	return &subcommand{
+		name:  "clear",
+		usage: "clear",
+		flags: pflag.NewFlagSet("clear", pflag.ExitOnError),
+		build: func(rest []string) (string, []string, error) {
+			return "saltutil.clear_cache", rest, nil
+		},
+	}
+}
+
+func testCommand() *subcommand {
+	return &subcommand{
+		name:  "test",
+		usage: "test",
+		flags: pflag.NewFlagSet("test", pflag.ExitOnError),
+		build: func(rest []string) (string, []string, error) {
+			return "test.ping", rest, nil
+		},
+	}
+}
+
+func pillarCommand() *subcommand {
+	return &subcommand{
+		name:  "pillar",
+		usage: "pillar get <key> | pillar items",
+		flags: pflag.NewFlagSet("pillar", pflag.ExitOnError),
+		build: func(rest []string) (string, []string, error) {
+			if len(rest) == 0 {
+				return "", nil, fmt.Errorf("pillar requires a subcommand (get <key> or items)")
+			}
+			switch rest[0] {
+			case "get":
+				if len(rest) != 2 {
+					return "", nil, fmt.Errorf("pillar get requires exactly one key")
+				}
+				return "pillar.get", rest[1:], nil
+			case "items":
+				return "pillar.items", nil, nil
+			default:
+				return "", nil, fmt.Errorf("unknown pillar subcommand %q", rest[0])
+			}
+		},
+	}
+}
+
+func grainsCommand() *subcommand {
+	return &subcommand{
+		name:  "grains",
+		usage: "grains get <key> | grains items",
+		flags: pflag.NewFlagSet("grains", pflag.ExitOnError),
+		build: func(rest []string) (string, []string, error) {
+			if len(rest) == 0 {
+				return "", nil, fmt.Errorf("grains requires a subcommand (get <key> or items)")
+			}
+			switch rest[0] {
+			case "get":
+				if len(rest) != 2 {
+					return "", nil, fmt.Errorf("grains get requires exactly one key")
+				}
+				return "grains.get", rest[1:], nil
+			case "items":
+				return "grains.items", nil, nil
+			default:
+				return "", nil, fmt.Errorf("unknown grains subcommand %q", rest[0])
+			}
+		},
+	}
+}
+
+func cmdCommand() *subcommand {
+	return &subcommand{
+		name:  "cmd",
+		usage: "cmd <salt-function> [args...]",
+		flags: pflag.NewFlagSet("cmd", pflag.ExitOnError),
+		build: func(rest []string) (string, []string, error) {
+			if len(rest) == 0 {
+				return "", nil, fmt.Errorf("cmd requires a Salt function to call")
+			}
+			return rest[0], rest[1:], nil
+		},
+	}
+}
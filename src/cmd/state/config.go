@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// config holds the persistent defaults that state reads from a config
+// file so that every invocation doesn't need a long flag chain.
+type config struct {
+	Colour    bool
+	Debug     bool
+	Full      bool
+	UseMaster bool
+	Quiet     bool
+	OutPath   string
+	Target    string
+	Salt      string
+	SaltCall  string
+}
+
+// defaultConfigPath returns the default location of the state config
+// file, ~/.staterc.
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".staterc")
+}
+
+// loadConfig reads a simple `key = value` config file, one setting per
+// line, with `#` starting a comment. A missing file at path is not an
+// error; it just means no defaults are overridden.
+func loadConfig(path string) (*config, error) {
+	cfg := &config{
+		Salt:     salt,
+		SaltCall: saltCall,
+	}
+
+	if path == "" {
+		return cfg, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: malformed line %q", path, line, text)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "colour", "color":
+			cfg.Colour, err = strconv.ParseBool(value)
+		case "debug":
+			cfg.Debug, err = strconv.ParseBool(value)
+		case "full":
+			cfg.Full, err = strconv.ParseBool(value)
+		case "master":
+			cfg.UseMaster, err = strconv.ParseBool(value)
+		case "quiet":
+			cfg.Quiet, err = strconv.ParseBool(value)
+		case "outpath":
+			cfg.OutPath = value
+		case "target":
+			cfg.Target = value
+		case "salt":
+			cfg.Salt = value
+		case "salt-call", "saltcall":
+			cfg.SaltCall = value
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown setting %q", path, line, key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, line, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// configFlagValue does a minimal pre-scan of args for -config/--config,
+// so the config file can be loaded before the rest of the flags are
+// registered with their (possibly config-derived) defaults.
+func configFlagValue(args []string) string {
+	for i, arg := range args {
+		switch {
+		case arg == "-config" || arg == "--config":
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+		case strings.HasPrefix(arg, "-config="):
+			return strings.TrimPrefix(arg, "-config=")
+		case strings.HasPrefix(arg, "--config="):
+			return strings.TrimPrefix(arg, "--config=")
+		}
+	}
+	return defaultConfigPath()
+}
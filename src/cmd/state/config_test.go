@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	cfg, err := loadConfig(filepath.Join(t.TempDir(), "does-not-exist"))
+	if err != nil {
+		t.Fatalf("loadConfig on a missing file returned an error: %s", err)
+	}
+	if cfg.Salt != salt || cfg.SaltCall != saltCall {
+		t.Errorf("missing config file should fall back to the package defaults, got %+v", cfg)
+	}
+}
+
+func TestLoadConfigOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".staterc")
+	contents := `# a comment
+colour = true
+quiet = false
+outpath = /var/log/state.log
+target = '*'
+salt = /opt/salt/bin/salt
+salt-call = /opt/salt/bin/salt-call
+`
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig: %s", err)
+	}
+
+	if !cfg.Colour {
+		t.Error("colour = true was not applied")
+	}
+	if cfg.Quiet {
+		t.Error("quiet = false was not applied")
+	}
+	if cfg.OutPath != "/var/log/state.log" {
+		t.Errorf("outpath = %q, want /var/log/state.log", cfg.OutPath)
+	}
+	if cfg.Target != "'*'" {
+		t.Errorf("target = %q, want '*'", cfg.Target)
+	}
+	if cfg.Salt != "/opt/salt/bin/salt" || cfg.SaltCall != "/opt/salt/bin/salt-call" {
+		t.Errorf("salt/salt-call overrides not applied, got %+v", cfg)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{"malformed line", "this has no equals sign\n"},
+		{"unknown setting", "bogus = true\n"},
+		{"bad bool", "colour = sometimes\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".staterc")
+			if err := os.WriteFile(path, []byte(tt.contents), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := loadConfig(path); err == nil {
+				t.Errorf("loadConfig(%q) should have failed", tt.contents)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// stateResult mirrors the per-state fields Salt returns for a
+// state.sls/state.highstate run.
+type stateResult struct {
+	Result   *bool                  `json:"result" yaml:"result"`
+	Changes  map[string]interface{} `json:"changes" yaml:"changes"`
+	Duration float64                `json:"duration" yaml:"duration"`
+	Comment  string                 `json:"comment" yaml:"comment"`
+}
+
+// normalizedState is the flattened, ID-tagged form of a stateResult that
+// state emits in its own --format output.
+type normalizedState struct {
+	ID       string                 `json:"id" yaml:"id"`
+	Result   *bool                  `json:"result" yaml:"result"`
+	Changed  bool                   `json:"changed" yaml:"changed"`
+	Duration float64                `json:"duration" yaml:"duration"`
+	Comment  string                 `json:"comment" yaml:"comment"`
+	Changes  map[string]interface{} `json:"changes,omitempty" yaml:"changes,omitempty"`
+}
+
+// summary is a top-level pass/fail/changed tally across all states.
+type summary struct {
+	Succeeded int `json:"succeeded" yaml:"succeeded"`
+	Failed    int `json:"failed" yaml:"failed"`
+	Changed   int `json:"changed" yaml:"changed"`
+}
+
+// normalizedOutput is the document state writes for --format json/yaml
+// when the Salt return parses as a set of per-state results.
+type normalizedOutput struct {
+	States  []normalizedState `json:"states" yaml:"states"`
+	Summary summary           `json:"summary" yaml:"summary"`
+}
+
+// buildCaptureCommand is like buildCommand, but leaves Stdout unset so
+// the caller can capture it with cmd.Output().
+func buildCaptureCommand(args []string) (*exec.Cmd, error) {
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &exec.Cmd{
+		Path:   path,
+		Args:   args,
+		Stderr: os.Stderr,
+	}, nil
+}
+
+// parseStateResults tries to interpret a salt-call/salt --out=json
+// return as a single minion's map of state ID to stateResult. ok is
+// false if the return isn't shaped like a state run (e.g. test.ping,
+// pillar.get), in which case the raw return should be re-emitted as-is.
+func parseStateResults(raw []byte) (states map[string]stateResult, ok bool) {
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &wrapper); err != nil || len(wrapper) != 1 {
+		return nil, false
+	}
+
+	for _, v := range wrapper {
+		if err := json.Unmarshal(v, &states); err != nil {
+			return nil, false
+		}
+	}
+	// Salt keys every state result as "module_|-id_|-name_|-function".
+	for id := range states {
+		if !strings.Contains(id, "_|-") {
+			return nil, false
+		}
+	}
+	return states, true
+}
+
+// normalize turns a parsed state-result map into state's own output
+// document, tallying the summary counts as it goes.
+func normalize(states map[string]stateResult) normalizedOutput {
+	ids := make([]string, 0, len(states))
+	for id := range states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	out := normalizedOutput{States: make([]normalizedState, 0, len(ids))}
+	for _, id := range ids {
+		sr := states[id]
+		ns := normalizedState{
+			ID:       id,
+			Result:   sr.Result,
+			Changed:  len(sr.Changes) > 0,
+			Duration: sr.Duration,
+			Comment:  sr.Comment,
+			Changes:  sr.Changes,
+		}
+		out.States = append(out.States, ns)
+
+		switch {
+		case sr.Result == nil:
+		case *sr.Result:
+			out.Summary.Succeeded++
+		default:
+			out.Summary.Failed++
+		}
+		if ns.Changed {
+			out.Summary.Changed++
+		}
+	}
+	return out
+}
+
+// runStructured runs arglist (which must already include --out=json),
+// captures its output, and re-emits it in the requested format. It
+// returns the process exit code: nonzero if any state failed.
+func runStructured(arglist []string, format string) int {
+	cmd, err := buildCaptureCommand(arglist)
+	if err != nil {
+		fatalf(err, "failed to find %s (is salt installed?)", arglist[0])
+	}
+
+	raw, err := cmd.Output()
+	if err != nil {
+		fatalf(err, "failed to run %s", arglist[0])
+	}
+
+	var (
+		doc      interface{}
+		exitCode int
+	)
+
+	if states, ok := parseStateResults(raw); ok {
+		normalized := normalize(states)
+		doc = normalized
+		if normalized.Summary.Failed > 0 {
+			exitCode = 1
+		}
+	} else {
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			fatalf(err, "failed to parse Salt output")
+		}
+	}
+
+	var encoded []byte
+	switch format {
+	case "json":
+		encoded, err = json.MarshalIndent(doc, "", "  ")
+	case "yaml":
+		encoded, err = yaml.Marshal(doc)
+	default:
+		fatalf(fmt.Errorf("unknown format %q", format), "")
+	}
+	if err != nil {
+		fatalf(err, "failed to encode output as %s", format)
+	}
+
+	fmt.Println(string(encoded))
+	return exitCode
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestParseStateResultsStateRun(t *testing.T) {
+	raw := []byte(`{
+		"local": {
+			"file_|-motd_|-/etc/motd_|-managed": {
+				"result": true,
+				"changes": {"diff": "..."},
+				"duration": 12.5,
+				"comment": "File /etc/motd updated"
+			},
+			"pkg_|-curl_|-curl_|-installed": {
+				"result": false,
+				"changes": {},
+				"duration": 1.1,
+				"comment": "Failed to install curl"
+			}
+		}
+	}`)
+
+	states, ok := parseStateResults(raw)
+	if !ok {
+		t.Fatal("parseStateResults did not recognize a state-shaped return")
+	}
+	if len(states) != 2 {
+		t.Fatalf("got %d states, want 2", len(states))
+	}
+}
+
+func TestParseStateResultsNonStateReturn(t *testing.T) {
+	tests := [][]byte{
+		[]byte(`{"local": true}`),
+		[]byte(`{"local": "pong"}`),
+		[]byte(`not json`),
+		[]byte(`{"minion-a": {}, "minion-b": {}}`),
+	}
+
+	for _, raw := range tests {
+		if _, ok := parseStateResults(raw); ok {
+			t.Errorf("parseStateResults(%s) should not have parsed as a state run", raw)
+		}
+	}
+}
+
+func TestNormalizeSummary(t *testing.T) {
+	states := map[string]stateResult{
+		"b_state": {Result: boolPtr(true), Changes: map[string]interface{}{"a": 1}},
+		"a_state": {Result: boolPtr(false)},
+		"c_state": {Result: nil},
+	}
+
+	out := normalize(states)
+
+	if len(out.States) != 3 {
+		t.Fatalf("got %d states, want 3", len(out.States))
+	}
+	if out.States[0].ID != "a_state" || out.States[1].ID != "b_state" || out.States[2].ID != "c_state" {
+		t.Errorf("states should be sorted by ID, got %v", out.States)
+	}
+
+	want := summary{Succeeded: 1, Failed: 1, Changed: 1}
+	if out.Summary != want {
+		t.Errorf("summary = %+v, want %+v", out.Summary, want)
+	}
+}
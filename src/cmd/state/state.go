@@ -1,11 +1,13 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"strings"
+
+	"github.com/spf13/pflag"
 )
 
 var (
@@ -18,32 +20,56 @@ var (
 	outFile     = "--out-file"
 	salt        = "salt"
 	saltCall    = "salt-call"
+
+	// rootFlags is set up in main and referenced by usage for -h output.
+	rootFlags *pflag.FlagSet
 )
 
 func usage(w io.Writer) {
-	fmt.Fprintf(w, `usage: state [-dgm] action [args...]
+	fmt.Fprintf(w, `usage: state [flags] command [args...]
 state is a wrapper for commonly used salt functions. It defaults to
 using salt-call --local for local state testing and management.
 
-Actions:
-	sls		Apply a salt state. This requires at least one argument
-			that is the state to apply.
+Commands:
+	sls		Apply one or more salt states.
 	up		Run a highstate.
 	highstate	Run a highstate.
 	sync		Sync Salt and Pillar.
 	clear		Clear the minion cache.
-	
+	test		Ping the minion (test.ping).
+	pillar		Query pillar data (get <key> or items).
+	grains		Query grain data (get <key> or items).
+	cmd		Call an arbitrary Salt function.
+	watch		Re-run a highstate (or sls) when watched paths change.
+
+Run "state command -h" for a command's own flags.
+
 Flags:
-	-c	Turn on coloured output.
-	-d	Use the DEBUG level of logging in the Salt binary.
-	-f	Also write output to the specified file.
-	-g	The Salt command should be global (e.g. use salt instead
-		of salt-call); the first argument after the action should
-		be a target spec; implies -m.
-	-m	Use the salt master (e.g. no --local).
-	-q	Quiet mode: only show warning and error log messages.
-	-v	Show full Salt output, instead of just changes.
 `)
+	if rootFlags != nil {
+		rootFlags.PrintDefaults()
+	}
+}
+
+// shellQuote joins args into a single string suitable for pasting into
+// a shell, quoting any argument that contains characters a shell would
+// otherwise treat specially.
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`*?[]{}()<>|&;!#~") {
+			quoted[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+		} else {
+			quoted[i] = arg
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// printCommand writes the shell-escaped form of arglist to stdout, as
+// state would invoke it.
+func printCommand(arglist []string) {
+	fmt.Println(shellQuote(arglist))
 }
 
 const localOnly = true
@@ -73,9 +99,19 @@ func fatalf(err error, format string, args ...interface{}) {
 	os.Exit(1)
 }
 
-func sync(arglist, argv []string) {
-	pillarSync := append(arglist, "saltutil.refresh_pillar")
+func syncAction(arglist, argv []string, dryRun bool) {
+	pillarSync := append(append([]string{}, arglist...), "saltutil.refresh_pillar")
 	pillarSync = append(pillarSync, argv...)
+
+	saltSync := append(append([]string{}, arglist...), "saltutil.sync_all")
+	saltSync = append(saltSync, argv...)
+
+	if dryRun {
+		printCommand(pillarSync)
+		printCommand(saltSync)
+		os.Exit(0)
+	}
+
 	cmd, err := buildCommand(pillarSync)
 	if err != nil {
 		fatalf(err, "failed to find %s (is salt installed?)", pillarSync[0])
@@ -86,9 +122,7 @@ func sync(arglist, argv []string) {
 		fatalf(err, "failed to refresh pillar")
 	}
 
-	saltSync := append(arglist, "saltutil.sync_all")
-	saltSync = append(saltSync, argv...)
-	cmd, err = buildCommand(pillarSync)
+	cmd, err = buildCommand(saltSync)
 	if err != nil {
 		fatalf(err, "failed to find %s (is salt installed?)", saltSync[0])
 	}
@@ -105,6 +139,7 @@ func main() {
 		// Flags.
 		colour    bool
 		debug     bool
+		dryRun    bool
 		full      bool
 		global    bool
 		quiet     bool
@@ -112,51 +147,77 @@ func main() {
 
 		// Options.
 		outPath string
+		format  string
 
 		// Argument handling.
 		arglist []string
-		argc    int
-		argv    []string
 		action  string
+		rest    []string
 		target  string
+
+		// Config file.
+		configPath string
 	)
 
-	flag.BoolVar(&colour, "c", false, "Turn on coloured output.")
-	flag.BoolVar(&debug, "d", false, "Turn on debug logging.")
-	flag.StringVar(&outPath, "f", "", "Also write logs to the named file.")
-	flag.BoolVar(&global, "g", false, "Global salt command.")
-	flag.BoolVar(&useMaster, "m", false, "Use the Salt master.")
-	flag.BoolVar(&quiet, "q", false, "Only show warnings and errors in logs.")
-	flag.BoolVar(&full, "v", false, "Show full output.")
-	flag.Parse()
-
-	argc = flag.NArg()
-	argv = flag.Args()
-	if argc == 0 {
+	cfg, err := loadConfig(configFlagValue(os.Args[1:]))
+	if err != nil {
+		fatalf(err, "failed to load config")
+	}
+
+	root := pflag.NewFlagSet("state", pflag.ExitOnError)
+	root.SetInterspersed(false)
+	root.Usage = func() { usage(os.Stderr) }
+	rootFlags = root
+
+	root.BoolVarP(&colour, "colour", "c", cfg.Colour, "Turn on coloured output.")
+	root.BoolVarP(&debug, "debug", "d", cfg.Debug, "Turn on debug logging.")
+	root.StringVarP(&outPath, "out", "f", cfg.OutPath, "Also write logs to the named file.")
+	root.BoolVarP(&global, "global", "g", false, "Global salt command; implies -m.")
+	root.BoolVarP(&useMaster, "master", "m", cfg.UseMaster, "Use the Salt master.")
+	root.BoolVarP(&dryRun, "dry-run", "n", false, "Print the command instead of running it.")
+	root.BoolVarP(&quiet, "quiet", "q", cfg.Quiet, "Only show warnings and errors in logs.")
+	root.BoolVarP(&full, "full", "v", cfg.Full, "Show full output.")
+	root.StringVar(&configPath, "config", defaultConfigPath(), "Path to a config file of defaults.")
+	root.StringVar(&format, "format", "text", "Output format: text, json, or yaml.")
+
+	if err := root.Parse(os.Args[1:]); err != nil {
+		fatalf(err, "failed to parse flags")
+	}
+
+	switch format {
+	case "text", "json", "yaml":
+	default:
+		fatalf(fmt.Errorf("unknown format %q", format), "")
+	}
+
+	args := root.Args()
+	if len(args) == 0 {
 		usage(os.Stdout)
 		return
 	}
 
-	action = argv[0]
-	argv = argv[1:]
-	argc--
+	action = args[0]
+	rest = args[1:]
 
 	if global {
-		if argc == 0 {
-			usage(os.Stderr)
-			os.Exit(1)
+		if len(rest) == 0 {
+			target = cfg.Target
+			if target == "" {
+				usage(os.Stderr)
+				os.Exit(1)
+			}
+		} else {
+			target = rest[0]
+			rest = rest[1:]
 		}
-		target = argv[0]
-		argv = argv[1:]
-		argc--
 	}
 
 	if global {
-		arglist = append(arglist, salt)
+		arglist = append(arglist, cfg.Salt)
 		arglist = append(arglist, target)
 		useMaster = true
 	} else {
-		arglist = append(arglist, saltCall)
+		arglist = append(arglist, cfg.SaltCall)
 	}
 
 	if !useMaster {
@@ -182,38 +243,64 @@ func main() {
 		arglist = append(arglist, outPath)
 	}
 
-	switch action {
-	case "sls":
-		if argc == 0 {
-			usage(os.Stderr)
-			os.Exit(1)
-		}
+	if debug {
+		arglist = append(arglist, logDebug...)
+	}
 
-		arglist = append(arglist, "state.sls")
-		arglist = append(arglist, argv...)
-	case "up", "highstate":
-		arglist = append(arglist, "state.highstate")
-		arglist = append(arglist, argv...)
-	case "sync":
-		sync(arglist, argv)
-	case "clear":
-		arglist = append(arglist, "saltutil.clear_cache")
-	default:
+	if action == "sync" {
+		syncAction(arglist, rest, dryRun)
+		return
+	}
+
+	if action == "watch" {
+		watch(arglist, rest, dryRun)
+		return
+	}
+
+	cmd, ok := commands[action]
+	if !ok {
 		usage(os.Stdout)
 		return
 	}
 
-	cmd, err := buildCommand(arglist)
+	cmd.flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: state %s\n", cmd.usage)
+		cmd.flags.PrintDefaults()
+	}
+	if err := cmd.flags.Parse(rest); err != nil {
+		fatalf(err, "failed to parse %s flags", cmd.name)
+	}
+
+	module, moduleArgs, err := cmd.build(cmd.flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "state %s: %s\n", cmd.name, err)
+		cmd.flags.Usage()
+		os.Exit(1)
+	}
+
+	arglist = append(arglist, module)
+	arglist = append(arglist, moduleArgs...)
+
+	if format != "text" {
+		arglist = append(arglist, "--out=json")
+	}
+
+	if dryRun {
+		printCommand(arglist)
+		return
+	}
+
+	if format != "text" {
+		os.Exit(runStructured(arglist, format))
+	}
+
+	execCmd, err := buildCommand(arglist)
 	if err != nil {
 		fatalf(err, "failed to find %s (is salt installed?)", arglist[0])
 	}
 
-	err = cmd.Run()
+	err = execCmd.Run()
 	if err != nil {
 		fatalf(err, "")
 	}
 }
-
-func init() {
-	flag.Usage = func() { usage(os.Stdout) }
-}
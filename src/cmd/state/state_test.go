@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"salt-call", "--local", "state.sls", "foo"}, "salt-call --local state.sls foo"},
+		{[]string{"pillar=" + `{"key": "value"}`}, `'pillar={"key": "value"}'`},
+		{[]string{""}, "''"},
+		{[]string{"it's"}, `'it'\''s'`},
+	}
+
+	for _, tt := range tests {
+		if got := shellQuote(tt.args); got != tt.want {
+			t.Errorf("shellQuote(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
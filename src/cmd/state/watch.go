@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/pflag"
+)
+
+// parseSignal maps a signal name (e.g. "SIGTERM" or "TERM") to the
+// corresponding os.Signal, for use with --kill-signal.
+func parseSignal(name string) (os.Signal, error) {
+	switch strings.ToUpper(strings.TrimPrefix(name, "SIG")) {
+	case "HUP":
+		return syscall.SIGHUP, nil
+	case "INT":
+		return syscall.SIGINT, nil
+	case "TERM":
+		return syscall.SIGTERM, nil
+	case "KILL":
+		return syscall.SIGKILL, nil
+	case "USR1":
+		return syscall.SIGUSR1, nil
+	case "USR2":
+		return syscall.SIGUSR2, nil
+	default:
+		return nil, fmt.Errorf("unknown signal %q", name)
+	}
+}
+
+// addRecursive adds root and every directory beneath it to w, since
+// fsnotify only watches the directories it's explicitly given.
+func addRecursive(w *fsnotify.Watcher, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return w.Add(path)
+		}
+		return nil
+	})
+}
+
+// procHandle is the minimal surface runSupervisor needs from a running
+// process, factored out so tests can drive the supervisor's restart
+// logic without spawning real processes.
+type procHandle struct {
+	signal func(os.Signal) error
+	wait   func() error
+}
+
+// startProcess runs runArgs and returns a procHandle for it.
+func startProcess(runArgs []string) (procHandle, error) {
+	cmd, err := buildCommand(runArgs)
+	if err != nil {
+		return procHandle{}, err
+	}
+
+	fmt.Fprintf(os.Stderr, "watch: running %s\n", shellQuote(runArgs))
+	if err := cmd.Start(); err != nil {
+		return procHandle{}, err
+	}
+
+	return procHandle{signal: cmd.Process.Signal, wait: cmd.Wait}, nil
+}
+
+// runSupervisor owns the single in-flight invocation returned by start.
+// It reads restart requests off restarts (coalescing any that arrive
+// while a kill/start is in progress) and guarantees only one process is
+// ever running or being waited on at a time.
+func runSupervisor(start func() (procHandle, error), restarts <-chan struct{}, sig os.Signal) {
+	var (
+		current procHandle
+		running bool
+		done    = make(chan struct{})
+	)
+
+	launch := func() {
+		handle, err := start()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "watch: failed to start run: %s\n", err)
+			return
+		}
+
+		current = handle
+		running = true
+		go func(h procHandle) {
+			if err := h.wait(); err != nil {
+				fmt.Fprintf(os.Stderr, "watch: run failed: %s\n", err)
+			}
+			done <- struct{}{}
+		}(handle)
+	}
+
+	for {
+		select {
+		case <-restarts:
+			if running {
+				current.signal(sig)
+				<-done
+				running = false
+			}
+			launch()
+		case <-done:
+			running = false
+		}
+	}
+}
+
+// watch implements the `watch` action: it monitors paths for changes
+// and re-runs a highstate (or a single sls) after each debounce-quiet
+// period, killing any still-running salt invocation first.
+func watch(arglist, rest []string, dryRun bool) {
+	fs := pflag.NewFlagSet("watch", pflag.ExitOnError)
+	debounce := fs.Duration("debounce", 2*time.Second, "Quiet period after a change before re-running.")
+	killSignal := fs.String("kill-signal", "SIGTERM", "Signal sent to an in-progress run when a new change arrives.")
+	sls := fs.String("sls", "", "Run state.sls for this state instead of a highstate.")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: state watch [--debounce 2s] [--kill-signal SIGTERM] [--sls NAME] [path...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(rest); err != nil {
+		fatalf(err, "failed to parse watch flags")
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"/srv/salt", "/srv/pillar"}
+	}
+
+	sig, err := parseSignal(*killSignal)
+	if err != nil {
+		fatalf(err, "invalid --kill-signal")
+	}
+
+	module := "state.highstate"
+	var moduleArgs []string
+	if *sls != "" {
+		module = "state.sls"
+		moduleArgs = []string{*sls}
+	}
+
+	runArgs := append(append([]string{}, arglist...), module)
+	runArgs = append(runArgs, moduleArgs...)
+
+	if dryRun {
+		printCommand(runArgs)
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fatalf(err, "failed to create watcher")
+	}
+	defer watcher.Close()
+
+	for _, path := range paths {
+		if err := addRecursive(watcher, path); err != nil {
+			fatalf(err, "failed to watch %s", path)
+		}
+	}
+
+	restarts := make(chan struct{}, 1)
+	start := func() (procHandle, error) { return startProcess(runArgs) }
+	go runSupervisor(start, restarts, sig)
+
+	requestRestart := func() {
+		select {
+		case restarts <- struct{}{}:
+		default:
+		}
+	}
+
+	var timer *time.Timer
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(*debounce, requestRestart)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "watch: %s\n", err)
+		}
+	}
+}
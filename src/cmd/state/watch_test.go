@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+// TestRunSupervisorCoalescesRestarts drives runSupervisor with a fake,
+// in-process "command" and asserts that a burst of restart requests
+// arriving while a run is still in flight never results in more than one
+// process active at a time, and that the in-flight one is signaled and
+// waited on before the next is launched.
+func TestRunSupervisorCoalescesRestarts(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		active  int
+		maxSeen int
+		starts  int
+	)
+
+	started := make(chan struct{}, 10)
+
+	fakeStart := func() (procHandle, error) {
+		mu.Lock()
+		active++
+		starts++
+		if active > maxSeen {
+			maxSeen = active
+		}
+		mu.Unlock()
+
+		stopped := make(chan struct{})
+		var stopOnce sync.Once
+
+		h := procHandle{
+			signal: func(os.Signal) error {
+				stopOnce.Do(func() { close(stopped) })
+				return nil
+			},
+			wait: func() error {
+				<-stopped
+				mu.Lock()
+				active--
+				mu.Unlock()
+				return nil
+			},
+		}
+
+		started <- struct{}{}
+		return h, nil
+	}
+
+	restarts := make(chan struct{}, 1)
+	go runSupervisor(fakeStart, restarts, syscall.SIGTERM)
+
+	// First restart launches the initial "process".
+	restarts <- struct{}{}
+	<-started
+
+	// A burst of further changes arriving while it's still running must
+	// never be allowed to start a second process alongside the first;
+	// the restarts channel's buffer of 1 coalesces whatever is still
+	// pending when the supervisor gets a chance to look.
+	for i := 0; i < 3; i++ {
+		select {
+		case restarts <- struct{}{}:
+		default:
+		}
+	}
+
+	// At least one more run (killing the first and replacing it) must
+	// have happened as a result of the burst.
+	<-started
+
+	mu.Lock()
+	defer mu.Unlock()
+	if maxSeen > 1 {
+		t.Errorf("more than one process was active at once: maxSeen=%d", maxSeen)
+	}
+	if starts < 2 {
+		t.Errorf("got %d process starts, want at least 2 (initial + a restart)", starts)
+	}
+}